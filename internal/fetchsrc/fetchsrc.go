@@ -0,0 +1,460 @@
+// Package fetchsrc resolves the `sources:` section of build-info.yaml,
+// staging remote or local inputs into payload/ before the rest of the build
+// runs. URL sources are always verified by digest and local sources are
+// verified when a sha256 is given; git sources are pinned by ref instead. A
+// tampered or stale cache entry fails the build instead of silently shipping.
+package fetchsrc
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// Source describes a single entry in build-info.yaml's `sources:` list. Exactly
+// one of URL, Git or Path should be set.
+type Source struct {
+	URL     string `yaml:"url,omitempty"`
+	Git     string `yaml:"git,omitempty"`
+	Ref     string `yaml:"ref,omitempty"`
+	Path    string `yaml:"path,omitempty"`
+	SHA256  string `yaml:"sha256,omitempty"`
+	Dest    string `yaml:"dest"`
+	Extract string `yaml:"extract,omitempty"` // "zip", "tar.gz", or "msi"
+}
+
+// FetchAll resolves every source into projectDir's payload/ directory, at
+// its Dest relative to payload/, using cacheDir to store and reuse downloaded
+// artifacts. In offline mode, any url or git source not already present in
+// the cache fails the build; local sources are used in place.
+func FetchAll(sources []Source, projectDir, cacheDir string, offline bool) error {
+	if err := os.MkdirAll(cacheDir, os.ModePerm); err != nil {
+		return fmt.Errorf("failed to create source cache dir: %w", err)
+	}
+
+	for i, src := range sources {
+		dest := filepath.Join(projectDir, "payload", src.Dest)
+
+		fetched, isDir, err := resolve(src, cacheDir, offline)
+		if err != nil {
+			return fmt.Errorf("source %d (%s): %w", i, src.describe(), err)
+		}
+
+		if err := stage(fetched, isDir, dest, src.Extract); err != nil {
+			return fmt.Errorf("source %d (%s): %w", i, src.describe(), err)
+		}
+	}
+
+	return nil
+}
+
+func (s Source) describe() string {
+	switch {
+	case s.URL != "":
+		return s.URL
+	case s.Git != "":
+		return s.Git
+	default:
+		return s.Path
+	}
+}
+
+// resolve fetches (or reuses a cached copy of) src and returns the local path
+// to the fetched content, along with whether that path is a directory.
+func resolve(src Source, cacheDir string, offline bool) (path string, isDir bool, err error) {
+	switch {
+	case src.URL != "":
+		path, err := fetchHTTP(src, cacheDir, offline)
+		return path, false, err
+	case src.Git != "":
+		path, err := fetchGit(src, cacheDir, offline)
+		return path, true, err
+	case src.Path != "":
+		path, err := fetchLocal(src)
+		return path, false, err
+	default:
+		return "", false, fmt.Errorf("source has none of url, git, or path set")
+	}
+}
+
+// fetchHTTP downloads src.URL into a content-addressed cache entry keyed by
+// src.SHA256, verifying the digest of whatever it downloads (or whatever is
+// already cached).
+func fetchHTTP(src Source, cacheDir string, offline bool) (string, error) {
+	if src.SHA256 == "" {
+		return "", fmt.Errorf("url source is missing a sha256 digest")
+	}
+
+	cachePath := filepath.Join(cacheDir, src.SHA256)
+	if _, err := os.Stat(cachePath); err == nil {
+		sum, err := sha256File(cachePath)
+		if err != nil {
+			return "", err
+		}
+		if sum != src.SHA256 {
+			return "", fmt.Errorf("cached file %s has digest %s, expected %s", cachePath, sum, src.SHA256)
+		}
+		return cachePath, nil
+	}
+
+	if offline {
+		return "", fmt.Errorf("%s is not cached and offline mode is enabled", src.URL)
+	}
+
+	resp, err := http.Get(src.URL)
+	if err != nil {
+		return "", fmt.Errorf("failed to fetch %s: %w", src.URL, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("failed to fetch %s: unexpected status %s", src.URL, resp.Status)
+	}
+
+	tmpPath := cachePath + ".download"
+	f, err := os.Create(tmpPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to create %s: %w", tmpPath, err)
+	}
+
+	h := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(f, h), resp.Body); err != nil {
+		f.Close()
+		os.Remove(tmpPath)
+		return "", fmt.Errorf("failed to download %s: %w", src.URL, err)
+	}
+	if err := f.Close(); err != nil {
+		return "", err
+	}
+
+	sum := hex.EncodeToString(h.Sum(nil))
+	if sum != src.SHA256 {
+		os.Remove(tmpPath)
+		return "", fmt.Errorf("%s has digest %s, expected %s", src.URL, sum, src.SHA256)
+	}
+
+	if err := os.Rename(tmpPath, cachePath); err != nil {
+		return "", fmt.Errorf("failed to place %s in cache: %w", src.URL, err)
+	}
+	return cachePath, nil
+}
+
+// fetchLocal verifies src.Path against src.SHA256 (when given) and returns it
+// unchanged; local sources are used in place rather than copied into the cache.
+func fetchLocal(src Source) (string, error) {
+	if _, err := os.Stat(src.Path); err != nil {
+		return "", fmt.Errorf("local source %s: %w", src.Path, err)
+	}
+	if src.SHA256 == "" {
+		return src.Path, nil
+	}
+	sum, err := sha256File(src.Path)
+	if err != nil {
+		return "", err
+	}
+	if sum != src.SHA256 {
+		return "", fmt.Errorf("%s has digest %s, expected %s", src.Path, sum, src.SHA256)
+	}
+	return src.Path, nil
+}
+
+// fetchGit clones src.Git at src.Ref into a cache directory keyed by the repo
+// URL and ref. Unlike url/local sources, git sources are pinned by ref rather
+// than a content digest.
+func fetchGit(src Source, cacheDir string, offline bool) (string, error) {
+	key := gitCacheKey(src.Git, src.Ref)
+	dir := filepath.Join(cacheDir, "git", key)
+
+	if _, err := os.Stat(dir); err == nil {
+		return dir, nil
+	}
+
+	if offline {
+		return "", fmt.Errorf("%s@%s is not cached and offline mode is enabled", src.Git, src.Ref)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(dir), os.ModePerm); err != nil {
+		return "", err
+	}
+
+	var err error
+	if isCommitSHA(src.Ref) {
+		// --branch only resolves branch/tag refs, not arbitrary commits, so a
+		// SHA pin needs its own clone: init, fetch that one commit, check it out.
+		err = fetchGitSHA(src.Git, src.Ref, dir)
+	} else {
+		err = fetchGitRef(src.Git, src.Ref, dir)
+	}
+	if err != nil {
+		os.RemoveAll(dir)
+		return "", err
+	}
+
+	return dir, nil
+}
+
+// fetchGitRef shallow-clones repo at branch/tag ref (or the default branch,
+// if ref is empty) into dir.
+func fetchGitRef(repo, ref, dir string) error {
+	args := []string{"clone", "--depth", "1"}
+	if ref != "" {
+		args = append(args, "--branch", ref)
+	}
+	args = append(args, repo, dir)
+
+	cmd := exec.Command("git", args...)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("git clone failed: %w", err)
+	}
+	return nil
+}
+
+// fetchGitSHA fetches exactly commit sha from repo into dir. This relies on
+// the remote supporting fetch-by-commit (GitHub, GitLab and most modern
+// servers do via uploadpack.allowReachableSHA1InWant); servers that don't
+// will fail here with git's own error.
+func fetchGitSHA(repo, sha, dir string) error {
+	for _, args := range [][]string{
+		{"init", "-q", dir},
+		{"-C", dir, "remote", "add", "origin", repo},
+		{"-C", dir, "fetch", "--depth", "1", "origin", sha},
+		{"-C", dir, "checkout", "-q", "FETCH_HEAD"},
+	} {
+		cmd := exec.Command("git", args...)
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = os.Stderr
+		if err := cmd.Run(); err != nil {
+			return fmt.Errorf("git %v failed: %w", args, err)
+		}
+	}
+	return nil
+}
+
+// isCommitSHA reports whether ref looks like a git commit hash (full or
+// abbreviated) rather than a branch or tag name.
+func isCommitSHA(ref string) bool {
+	if len(ref) < 7 || len(ref) > 40 {
+		return false
+	}
+	for _, r := range ref {
+		if !(r >= '0' && r <= '9') && !(r >= 'a' && r <= 'f') && !(r >= 'A' && r <= 'F') {
+			return false
+		}
+	}
+	return true
+}
+
+func gitCacheKey(repo, ref string) string {
+	h := sha256.Sum256([]byte(repo + "@" + ref))
+	return hex.EncodeToString(h[:])
+}
+
+// stage copies or extracts fetched into dest. When extract is empty, fetched
+// is staged as-is (a single file copy, or a directory tree copy for git sources).
+func stage(fetched string, fetchedIsDir bool, dest, extract string) error {
+	if err := os.MkdirAll(filepath.Dir(dest), os.ModePerm); err != nil {
+		return fmt.Errorf("failed to create %s: %w", filepath.Dir(dest), err)
+	}
+
+	switch extract {
+	case "":
+		if fetchedIsDir {
+			return copyTree(fetched, dest)
+		}
+		return copyFile(fetched, dest)
+	case "zip":
+		return extractZip(fetched, dest)
+	case "tar.gz":
+		return extractTarGz(fetched, dest)
+	case "msi":
+		return extractMSI(fetched, dest)
+	default:
+		return fmt.Errorf("unsupported extract type %q", extract)
+	}
+}
+
+// safeJoin joins destDir and name, rejecting names (e.g. containing "../")
+// that would resolve outside destDir once cleaned. Archive entries are
+// untrusted input, so this guards against zip-slip style extraction.
+func safeJoin(destDir, name string) (string, error) {
+	destDir = filepath.Clean(destDir)
+	target := filepath.Join(destDir, name)
+	if target != destDir && !strings.HasPrefix(target, destDir+string(filepath.Separator)) {
+		return "", fmt.Errorf("archive entry %q escapes destination %s", name, destDir)
+	}
+	return target, nil
+}
+
+func extractZip(archivePath, destDir string) error {
+	r, err := zip.OpenReader(archivePath)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", archivePath, err)
+	}
+	defer r.Close()
+
+	for _, f := range r.File {
+		target, err := safeJoin(destDir, f.Name)
+		if err != nil {
+			return err
+		}
+		if f.FileInfo().IsDir() {
+			if err := os.MkdirAll(target, os.ModePerm); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := os.MkdirAll(filepath.Dir(target), os.ModePerm); err != nil {
+			return err
+		}
+		if err := extractZipEntry(f, target); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func extractZipEntry(f *zip.File, target string) error {
+	rc, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+
+	out, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, f.Mode())
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	_, err = io.Copy(out, rc)
+	return err
+}
+
+func extractTarGz(archivePath, destDir string) error {
+	f, err := os.Open(archivePath)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", archivePath, err)
+	}
+	defer f.Close()
+
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return fmt.Errorf("failed to read gzip: %w", err)
+	}
+	defer gz.Close()
+
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read tar entry: %w", err)
+		}
+
+		target, err := safeJoin(destDir, hdr.Name)
+		if err != nil {
+			return err
+		}
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(target, os.ModePerm); err != nil {
+				return err
+			}
+		case tar.TypeReg:
+			if err := os.MkdirAll(filepath.Dir(target), os.ModePerm); err != nil {
+				return err
+			}
+			out, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, os.FileMode(hdr.Mode))
+			if err != nil {
+				return err
+			}
+			if _, err := io.Copy(out, tr); err != nil {
+				out.Close()
+				return err
+			}
+			out.Close()
+		}
+	}
+}
+
+// extractMSI shells out to msiexec's administrative install, which unpacks
+// an MSI's contents without actually installing it.
+func extractMSI(msiPath, destDir string) error {
+	if err := os.MkdirAll(destDir, os.ModePerm); err != nil {
+		return err
+	}
+	cmd := exec.Command("msiexec", "/a", msiPath, "/qn", "TARGETDIR="+destDir)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
+
+func copyFile(src, dest string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.Create(dest)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	_, err = io.Copy(out, in)
+	return err
+}
+
+func copyTree(srcDir, destDir string) error {
+	return filepath.Walk(srcDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(srcDir, path)
+		if err != nil {
+			return err
+		}
+		if rel == "." {
+			return nil
+		}
+		if strings.HasPrefix(rel, ".git"+string(filepath.Separator)) || rel == ".git" {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		target := filepath.Join(destDir, rel)
+		if info.IsDir() {
+			return os.MkdirAll(target, os.ModePerm)
+		}
+		return copyFile(path, target)
+	})
+}
+
+func sha256File(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -0,0 +1,142 @@
+// Package nfpmbuild translates a gorilla-pkg payload and build-info into
+// Linux package artifacts (deb, rpm, apk, archlinux) by driving nfpm.
+package nfpmbuild
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/goreleaser/nfpm/v2"
+	"github.com/goreleaser/nfpm/v2/files"
+
+	// Side-effect imports register each packager with nfpm.
+	_ "github.com/goreleaser/nfpm/v2/apk"
+	_ "github.com/goreleaser/nfpm/v2/arch"
+	_ "github.com/goreleaser/nfpm/v2/deb"
+	_ "github.com/goreleaser/nfpm/v2/rpm"
+)
+
+// Input captures the parts of build-info.yaml and the project layout that
+// nfpm needs in order to build a package, decoupling this package from
+// gorilla-pkg's BuildInfo type.
+type Input struct {
+	Identifier  string
+	Name        string
+	Version     string
+	Developer   string
+	Description string
+	// InstallLocation is an absolute POSIX path (e.g. /opt/contoso/app), not
+	// the Windows install_location from build-info.yaml; the caller is
+	// responsible for translating between the two.
+	InstallLocation   string
+	PayloadDir        string
+	PreinstallScript  string
+	PostinstallScript string
+}
+
+// targetArch is the architecture nfpm builds for. gorilla-pkg payloads are
+// architecture-agnostic admin content, so we always target amd64.
+const targetArch = "amd64"
+
+// formatPlatform maps the nfpm formats gorilla-pkg supports to their platform.
+var formatPlatform = map[string]string{
+	"deb":       "linux",
+	"rpm":       "linux",
+	"apk":       "linux",
+	"archlinux": "linux",
+}
+
+// Build produces one package artifact per requested format into buildDir and
+// returns the paths of the artifacts it created. Unsupported formats are
+// rejected outright so a typo in build-info.yaml fails the build loudly.
+func Build(in Input, formats []string, buildDir string) ([]string, error) {
+	info, err := toNFPMInfo(in)
+	if err != nil {
+		return nil, err
+	}
+
+	var artifacts []string
+	for _, format := range formats {
+		packager, err := nfpm.Get(format)
+		if err != nil {
+			return nil, fmt.Errorf("unsupported nfpm format %q: %w", format, err)
+		}
+
+		info.Platform = formatPlatform[format]
+		pkgPath := filepath.Join(buildDir, packager.ConventionalFileName(info))
+
+		f, err := os.Create(pkgPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create %s: %w", pkgPath, err)
+		}
+		if err := packager.Package(info, f); err != nil {
+			f.Close()
+			return nil, fmt.Errorf("failed to build %s package: %w", format, err)
+		}
+		if err := f.Close(); err != nil {
+			return nil, fmt.Errorf("failed to finalize %s: %w", pkgPath, err)
+		}
+
+		artifacts = append(artifacts, pkgPath)
+	}
+
+	return artifacts, nil
+}
+
+// toNFPMInfo walks in.PayloadDir and assembles the nfpm.Info describing the
+// package contents and scripts.
+func toNFPMInfo(in Input) (*nfpm.Info, error) {
+	var contents files.Contents
+
+	if in.PayloadDir != "" {
+		if _, err := os.Stat(in.PayloadDir); err == nil {
+			err := filepath.Walk(in.PayloadDir, func(path string, fi fs.FileInfo, err error) error {
+				if err != nil {
+					return err
+				}
+				if fi.IsDir() {
+					return nil
+				}
+				relPath, err := filepath.Rel(in.PayloadDir, path)
+				if err != nil {
+					return err
+				}
+				dst := filepath.ToSlash(filepath.Join(in.InstallLocation, relPath))
+				contents = append(contents, &files.Content{
+					Source:      path,
+					Destination: dst,
+				})
+				return nil
+			})
+			if err != nil {
+				return nil, fmt.Errorf("error walking payload directory: %w", err)
+			}
+		}
+	}
+
+	info := &nfpm.Info{
+		Name:        in.Name,
+		Arch:        targetArch,
+		Version:     in.Version,
+		Maintainer:  in.Developer,
+		Description: in.Description,
+		Vendor:      in.Developer,
+		Overridables: nfpm.Overridables{
+			Contents: contents,
+			Scripts: nfpm.Scripts{
+				PreInstall:  in.PreinstallScript,
+				PostInstall: in.PostinstallScript,
+			},
+		},
+	}
+
+	return info, nil
+}
+
+// SupportedFormats lists the nfpm-backed formats gorilla-pkg knows how to
+// produce, for use in build-info.yaml validation and usage output.
+func SupportedFormats() []string {
+	return []string{"deb", "rpm", "apk", "archlinux"}
+}
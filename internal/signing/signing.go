@@ -0,0 +1,151 @@
+// Package signing provides the Signer interface gorilla-pkg uses to
+// authenticode-sign build artifacts, along with implementations for the
+// local certificate store, Azure Key Vault, AWS KMS, and PKCS#11 hardware
+// tokens. The backend is selected by the `signing:` block in build-info.yaml.
+package signing
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+)
+
+// Config mirrors the `signing:` block in build-info.yaml.
+type Config struct {
+	Provider     string `yaml:"provider,omitempty"`
+	KeyID        string `yaml:"key_id,omitempty"`
+	Tenant       string `yaml:"tenant,omitempty"`
+	TimestampURL string `yaml:"timestamp_url,omitempty"`
+	DigestAlg    string `yaml:"digest_alg,omitempty"`
+}
+
+// Signer authenticode-signs the artifact at path.
+type Signer interface {
+	Sign(path string) error
+}
+
+// New selects a Signer implementation for cfg.Provider. An empty Provider
+// defaults to "local" for backward compatibility with the plain
+// signing_certificate field.
+func New(cfg Config) (Signer, error) {
+	if cfg.TimestampURL == "" {
+		cfg.TimestampURL = "http://timestamp.digicert.com"
+	}
+	if cfg.DigestAlg == "" {
+		cfg.DigestAlg = "SHA256"
+	}
+
+	switch cfg.Provider {
+	case "", "local":
+		if cfg.KeyID == "" {
+			return nil, fmt.Errorf("local signing requires key_id (certificate subject or thumbprint)")
+		}
+		return &LocalCertSigner{cfg: cfg}, nil
+	case "azurekeyvault":
+		if cfg.KeyID == "" || cfg.Tenant == "" {
+			return nil, fmt.Errorf("azurekeyvault signing requires key_id and tenant")
+		}
+		return &AzureKeyVaultSigner{cfg: cfg}, nil
+	case "awskms":
+		if cfg.KeyID == "" {
+			return nil, fmt.Errorf("awskms signing requires key_id (KMS key ARN)")
+		}
+		return &AWSKMSSigner{cfg: cfg}, nil
+	case "pkcs11":
+		if cfg.KeyID == "" {
+			return nil, fmt.Errorf("pkcs11 signing requires key_id (token key label)")
+		}
+		return &PKCS11Signer{cfg: cfg}, nil
+	default:
+		return nil, fmt.Errorf("unsupported signing provider %q", cfg.Provider)
+	}
+}
+
+// LocalCertSigner signs using a certificate already installed in the local
+// machine certificate store, via signtool.exe. This is gorilla-pkg's
+// original signing behavior.
+type LocalCertSigner struct {
+	cfg Config
+}
+
+func (s *LocalCertSigner) Sign(path string) error {
+	return runCommand("signtool", "sign", "/n", s.cfg.KeyID,
+		"/fd", s.cfg.DigestAlg, "/tr", s.cfg.TimestampURL, "/td", s.cfg.DigestAlg, path)
+}
+
+// AzureKeyVaultSigner signs via AzureSignTool, which authenticates to Azure
+// AD (using Tenant) and signs with a certificate held in Azure Key Vault
+// (identified by KeyID) without ever exporting the private key locally.
+type AzureKeyVaultSigner struct {
+	cfg Config
+}
+
+func (s *AzureKeyVaultSigner) Sign(path string) error {
+	return runCommand("AzureSignTool", "sign",
+		"-kvu", s.cfg.KeyID,
+		"-kvt", s.cfg.Tenant,
+		"-tr", s.cfg.TimestampURL,
+		"-td", s.cfg.DigestAlg,
+		path)
+}
+
+// AWSKMSSigner signs through signtool's CNG key storage provider backed by
+// AWS KMS, keeping the private key in KMS.
+type AWSKMSSigner struct {
+	cfg Config
+}
+
+func (s *AWSKMSSigner) Sign(path string) error {
+	return runCommand("signtool", "sign",
+		"/csp", "AWS KMS Key Storage Provider",
+		"/kc", s.cfg.KeyID,
+		"/fd", s.cfg.DigestAlg,
+		"/tr", s.cfg.TimestampURL,
+		"/td", s.cfg.DigestAlg,
+		path)
+}
+
+// PKCS11Signer signs using a hardware token (smart card or HSM) exposed
+// through a PKCS#11 library, addressed by a key label in KeyID.
+type PKCS11Signer struct {
+	cfg Config
+}
+
+func (s *PKCS11Signer) Sign(path string) error {
+	return runCommand("signtool", "sign",
+		"/csp", "PKCS11 Key Storage Provider",
+		"/kc", s.cfg.KeyID,
+		"/fd", s.cfg.DigestAlg,
+		"/tr", s.cfg.TimestampURL,
+		"/td", s.cfg.DigestAlg,
+		path)
+}
+
+// NuGetSigner signs a .nupkg with NuGet's own package signature format
+// (nuget sign) rather than authenticode-signing the archive with signtool.
+type NuGetSigner struct {
+	cfg Config
+}
+
+// NewNuGetSigner builds a NuGetSigner from cfg, for callers that want NuGet
+// package signatures on a .nupkg instead of the Signer interface's
+// authenticode signing.
+func NewNuGetSigner(cfg Config) *NuGetSigner {
+	if cfg.TimestampURL == "" {
+		cfg.TimestampURL = "http://timestamp.digicert.com"
+	}
+	return &NuGetSigner{cfg: cfg}
+}
+
+func (s *NuGetSigner) Sign(path string) error {
+	return runCommand("nuget", "sign", path,
+		"-CertificateSubjectName", s.cfg.KeyID,
+		"-Timestamper", s.cfg.TimestampURL)
+}
+
+func runCommand(command string, args ...string) error {
+	cmd := exec.Command(command, args...)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
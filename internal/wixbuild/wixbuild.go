@@ -0,0 +1,319 @@
+// Package wixbuild generates a WiX source file from a gorilla-pkg payload and
+// build-info, then drives candle.exe/light.exe to produce a .msi. Signing the
+// .msi is left to the caller.
+package wixbuild
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// Input captures the parts of build-info.yaml and the project layout that
+// the WiX build needs, so this package doesn't import gorilla-pkg's
+// BuildInfo type directly.
+type Input struct {
+	Identifier        string
+	Name              string
+	Version           string
+	Developer         string
+	Description       string
+	InstallLocation   string
+	PayloadDir        string
+	PreinstallScript  string
+	PostinstallScript string
+}
+
+// wixNamespace is the WiX v3 schema namespace.
+const wixNamespace = "http://schemas.microsoft.com/wix/2006/wi"
+
+type wixRoot struct {
+	XMLName xml.Name   `xml:"Wix"`
+	Xmlns   string     `xml:"xmlns,attr"`
+	Product wixProduct `xml:"Product"`
+}
+
+type wixProduct struct {
+	Id                     string               `xml:"Id,attr"`
+	Name                   string               `xml:"Name,attr"`
+	Language               string               `xml:"Language,attr"`
+	Version                string               `xml:"Version,attr"`
+	Manufacturer           string               `xml:"Manufacturer,attr"`
+	UpgradeCode            string               `xml:"UpgradeCode,attr"`
+	Package                wixPackage           `xml:"Package"`
+	MajorUpgrade           wixMajorUpgrade      `xml:"MajorUpgrade"`
+	MediaTemplate          wixMediaTemplate     `xml:"MediaTemplate"`
+	Directory              wixDirNode           `xml:"Directory"`
+	Feature                wixFeature           `xml:"Feature"`
+	CustomAction           []wixCustomAction    `xml:"CustomAction,omitempty"`
+	InstallExecuteSequence wixInstallExecuteSeq `xml:"InstallExecuteSequence"`
+}
+
+type wixPackage struct {
+	InstallerVersion string `xml:"InstallerVersion,attr"`
+	Compressed       string `xml:"Compressed,attr"`
+	InstallScope     string `xml:"InstallScope,attr"`
+	Description      string `xml:"Description,attr,omitempty"`
+}
+
+type wixMajorUpgrade struct {
+	DowngradeErrorMessage string `xml:"DowngradeErrorMessage,attr"`
+}
+
+type wixMediaTemplate struct {
+	EmbedCab string `xml:"EmbedCab,attr"`
+}
+
+// wixDirNode is a single <Directory> element. Unlike wixProduct's other
+// children, Directory nests arbitrarily deep, so it references itself rather
+// than a fixed chain of types; INSTALLDIR's depth depends on how many path
+// segments in.InstallLocation has once the drive letter and "Program Files"
+// are stripped off (see installDirChain).
+type wixDirNode struct {
+	Id        string         `xml:"Id,attr"`
+	Name      string         `xml:"Name,attr,omitempty"`
+	Directory *wixDirNode    `xml:"Directory,omitempty"`
+	Component []wixComponent `xml:"Component,omitempty"`
+}
+
+type wixComponent struct {
+	Id    string    `xml:"Id,attr"`
+	Guid  string    `xml:"Guid,attr"`
+	Files []wixFile `xml:"File"`
+}
+
+type wixFile struct {
+	Id      string `xml:"Id,attr"`
+	Source  string `xml:"Source,attr"`
+	KeyPath string `xml:"KeyPath,attr"`
+}
+
+type wixFeature struct {
+	Id           string            `xml:"Id,attr"`
+	Title        string            `xml:"Title,attr"`
+	Level        string            `xml:"Level,attr"`
+	ComponentRef []wixComponentRef `xml:"ComponentRef"`
+}
+
+type wixComponentRef struct {
+	Id string `xml:"Id,attr"`
+}
+
+type wixCustomAction struct {
+	Id         string `xml:"Id,attr"`
+	Directory  string `xml:"Directory,attr,omitempty"`
+	ExeCommand string `xml:"ExeCommand,attr"`
+	Execute    string `xml:"Execute,attr"`
+	Return     string `xml:"Return,attr"`
+}
+
+type wixInstallExecuteSeq struct {
+	Custom []wixScheduledAction `xml:"Custom"`
+}
+
+type wixScheduledAction struct {
+	Action string `xml:"Action,attr"`
+	Before string `xml:"Before,attr,omitempty"`
+	After  string `xml:"After,attr,omitempty"`
+	Cond   string `xml:",chardata"`
+}
+
+// guidFor deterministically derives a GUID from seed so that rebuilding the
+// same package (e.g. for the UpgradeCode) always yields the same value.
+func guidFor(seed string) string {
+	return strings.ToUpper(uuid.NewSHA1(uuid.NameSpaceDNS, []byte(seed)).String())
+}
+
+// installDirChain turns installLocation (a Windows path such as
+// `C:\Program Files\Contoso\App`) into a chain of <Directory> elements
+// nested under ProgramFilesFolder, one per remaining path segment, with the
+// innermost one taking Id "INSTALLDIR" and containing component. A
+// Directory/@Name attribute is a single path segment, not a full path, so
+// the segments can't be collapsed into one element.
+func installDirChain(installLocation string, component wixComponent) *wixDirNode {
+	segments := strings.FieldsFunc(installLocation, func(r rune) bool {
+		return r == '\\' || r == '/'
+	})
+	if len(segments) > 0 && isDriveLetter(segments[0]) {
+		segments = segments[1:]
+	}
+	if len(segments) > 0 && strings.HasPrefix(strings.ToLower(segments[0]), "program files") {
+		segments = segments[1:]
+	}
+	if len(segments) == 0 {
+		segments = []string{"App"}
+	}
+
+	leaf := &wixDirNode{
+		Id:        "INSTALLDIR",
+		Name:      segments[len(segments)-1],
+		Component: []wixComponent{component},
+	}
+	node := leaf
+	for i := len(segments) - 2; i >= 0; i-- {
+		node = &wixDirNode{
+			Id:        fmt.Sprintf("INSTALLDIR_%d", i+1),
+			Name:      segments[i],
+			Directory: node,
+		}
+	}
+	return node
+}
+
+// isDriveLetter reports whether seg is a Windows drive letter like "C:".
+func isDriveLetter(seg string) bool {
+	return len(seg) == 2 && seg[1] == ':' &&
+		((seg[0] >= 'A' && seg[0] <= 'Z') || (seg[0] >= 'a' && seg[0] <= 'z'))
+}
+
+// Build renders a .wxs from in and compiles it with candle.exe/light.exe into
+// buildDir, returning the path to the resulting .msi. Signing is the
+// caller's responsibility (see gorilla-pkg's signPackage), so that the MSI
+// goes through the same pluggable signing backend as every other format.
+func Build(in Input, buildDir string) (string, error) {
+	wxsPath := filepath.Join(buildDir, in.Name+".wxs")
+	if err := writeWxs(in, wxsPath); err != nil {
+		return "", err
+	}
+
+	wixobjPath := filepath.Join(buildDir, in.Name+".wixobj")
+	if err := runCommand("candle.exe", "-out", wixobjPath, wxsPath); err != nil {
+		return "", fmt.Errorf("candle.exe failed: %w", err)
+	}
+
+	msiPath := filepath.Join(buildDir, in.Name+"-"+in.Version+".msi")
+	if err := runCommand("light.exe", "-out", msiPath, wixobjPath); err != nil {
+		return "", fmt.Errorf("light.exe failed: %w", err)
+	}
+
+	return msiPath, nil
+}
+
+// writeWxs walks in.PayloadDir and renders the WiX source describing the
+// install, scheduling pre/postinstall scripts as CustomActions around
+// InstallFiles.
+func writeWxs(in Input, wxsPath string) error {
+	componentID := "MainComponent"
+	component := wixComponent{
+		Id:   componentID,
+		Guid: guidFor(in.Identifier + "/component/" + in.Version),
+	}
+
+	if in.PayloadDir != "" {
+		if _, err := os.Stat(in.PayloadDir); err == nil {
+			idx := 0
+			err := filepath.Walk(in.PayloadDir, func(path string, fi fs.FileInfo, err error) error {
+				if err != nil {
+					return err
+				}
+				if fi.IsDir() {
+					return nil
+				}
+				idx++
+				fileID := fmt.Sprintf("File%d", idx)
+				keyPath := "no"
+				if idx == 1 {
+					keyPath = "yes"
+				}
+				component.Files = append(component.Files, wixFile{
+					Id:      fileID,
+					Source:  path,
+					KeyPath: keyPath,
+				})
+				return nil
+			})
+			if err != nil {
+				return fmt.Errorf("error walking payload directory: %w", err)
+			}
+		}
+	}
+
+	var customActions []wixCustomAction
+	var sequenced []wixScheduledAction
+	if in.PreinstallScript != "" {
+		customActions = append(customActions, wixCustomAction{
+			Id:         "PreinstallScript",
+			ExeCommand: fmt.Sprintf(`powershell.exe -NoProfile -ExecutionPolicy Bypass -File "%s"`, in.PreinstallScript),
+			Execute:    "deferred",
+			Return:     "check",
+		})
+		sequenced = append(sequenced, wixScheduledAction{Action: "PreinstallScript", Before: "InstallFiles"})
+	}
+	if in.PostinstallScript != "" {
+		customActions = append(customActions, wixCustomAction{
+			Id:         "PostinstallScript",
+			ExeCommand: fmt.Sprintf(`powershell.exe -NoProfile -ExecutionPolicy Bypass -File "%s"`, in.PostinstallScript),
+			Execute:    "deferred",
+			Return:     "check",
+		})
+		sequenced = append(sequenced, wixScheduledAction{Action: "PostinstallScript", After: "InstallFiles"})
+	}
+
+	root := wixRoot{
+		Xmlns: wixNamespace,
+		Product: wixProduct{
+			Id:           "*",
+			Name:         in.Name,
+			Language:     "1033",
+			Version:      in.Version,
+			Manufacturer: in.Developer,
+			UpgradeCode:  guidFor(in.Identifier + "/upgrade-code"),
+			Package: wixPackage{
+				InstallerVersion: "500",
+				Compressed:       "yes",
+				InstallScope:     "perMachine",
+				Description:      in.Description,
+			},
+			MajorUpgrade:  wixMajorUpgrade{DowngradeErrorMessage: "A newer version of [ProductName] is already installed."},
+			MediaTemplate: wixMediaTemplate{EmbedCab: "yes"},
+			Directory: wixDirNode{
+				Id:   "TARGETDIR",
+				Name: "SourceDir",
+				Directory: &wixDirNode{
+					Id:        "ProgramFilesFolder",
+					Name:      "PFiles",
+					Directory: installDirChain(in.InstallLocation, component),
+				},
+			},
+			Feature: wixFeature{
+				Id:           "MainFeature",
+				Title:        in.Name,
+				Level:        "1",
+				ComponentRef: []wixComponentRef{{Id: componentID}},
+			},
+			CustomAction:           customActions,
+			InstallExecuteSequence: wixInstallExecuteSeq{Custom: sequenced},
+		},
+	}
+
+	f, err := os.Create(wxsPath)
+	if err != nil {
+		return fmt.Errorf("failed to create .wxs file: %w", err)
+	}
+	defer f.Close()
+
+	if _, err := f.WriteString(xml.Header); err != nil {
+		return err
+	}
+
+	encoder := xml.NewEncoder(f)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(root); err != nil {
+		return fmt.Errorf("failed to encode .wxs: %w", err)
+	}
+
+	return nil
+}
+
+func runCommand(command string, args ...string) error {
+	cmd := exec.Command(command, args...)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
@@ -1,27 +1,45 @@
 package main
 
 import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"encoding/xml"
 	"flag"
 	"fmt"
+	"io"
 	"io/fs"
 	"log"
 	"os"
 	"os/exec"
+	"path"
 	"path/filepath"
+	"runtime"
 	"sort"
 	"strconv"
 	"strings"
 
+	"golang.org/x/sync/errgroup"
 	"gopkg.in/yaml.v2"
+
+	"github.com/windowsadmins/gorilla-pkg/internal/fetchsrc"
+	"github.com/windowsadmins/gorilla-pkg/internal/nfpmbuild"
+	"github.com/windowsadmins/gorilla-pkg/internal/signing"
+	"github.com/windowsadmins/gorilla-pkg/internal/wixbuild"
 )
 
 // BuildInfo holds package build information parsed from YAML.
 type BuildInfo struct {
-	InstallLocation    string `yaml:"install_location"`
-	PostInstallAction  string `yaml:"postinstall_action"`
-	SigningCertificate string `yaml:"signing_certificate,omitempty"`
-	Product            struct {
+	InstallLocation      string            `yaml:"install_location"`
+	LinuxInstallLocation string            `yaml:"linux_install_location,omitempty"`
+	PostInstallAction    string            `yaml:"postinstall_action"`
+	SigningCertificate   string            `yaml:"signing_certificate,omitempty"`
+	Signing              signing.Config    `yaml:"signing,omitempty"`
+	NuGetSign            bool              `yaml:"nuget_sign,omitempty"`
+	Formats              []string          `yaml:"formats,omitempty"`
+	Sources              []fetchsrc.Source `yaml:"sources,omitempty"`
+	Product              struct {
 		Identifier  string `yaml:"identifier"`
 		Version     string `yaml:"version"`
 		Name        string `yaml:"name"`
@@ -53,6 +71,17 @@ type FileRef struct {
 	Target string `xml:"target,attr"`
 }
 
+// ManifestEntry describes a single payload file for the content-addressed
+// deploy manifest: where it lives relative to payload/, where it lands on
+// disk, and the digest used to detect drift or skip an unchanged copy.
+type ManifestEntry struct {
+	Path   string `json:"path"`
+	Target string `json:"target"`
+	SHA256 string `json:"sha256"`
+	Size   int64  `json:"size"`
+	Mode   uint32 `json:"mode"`
+}
+
 func setupLogging(verbose bool) {
 	log.SetFlags(log.Ldate | log.Ltime | log.Lshortfile)
 	if verbose {
@@ -147,6 +176,17 @@ func normalizeInstallLocation(path string) string {
 	return path
 }
 
+// linuxInstallLocation returns the absolute POSIX path nfpm packages should
+// install into. install_location is a Windows path (drive letter, backslashes)
+// and isn't valid here, so Linux formats need their own location: explicit
+// linux_install_location, or /opt/<identifier> if that isn't set.
+func linuxInstallLocation(buildInfo *BuildInfo) string {
+	if buildInfo.LinuxInstallLocation != "" {
+		return path.Clean("/" + buildInfo.LinuxInstallLocation)
+	}
+	return "/opt/" + buildInfo.Product.Identifier
+}
+
 // getPreinstallScripts returns all scripts matching `preinstall*.ps1`
 func getPreinstallScripts(projectDir string) ([]string, error) {
 	scriptsDir := filepath.Join(projectDir, "scripts")
@@ -228,25 +268,23 @@ func includePreinstallScripts(projectDir string) error {
 	return nil
 }
 
-// createChocolateyInstallScript generates chocolateyInstall.ps1 and appends postinstall scripts.
-func createChocolateyInstallScript(buildInfo *BuildInfo, projectDir string) error {
+// createChocolateyInstallScript generates chocolateyInstall.ps1 against an
+// already-computed manifest (see buildPayloadManifest); the manifest itself
+// is expected to already be written to tools/manifest.json by the caller.
+func createChocolateyInstallScript(buildInfo *BuildInfo, projectDir string, manifest []ManifestEntry) error {
 	scriptPath := filepath.Join(projectDir, "tools", "chocolateyInstall.ps1")
 
-	// Check if the payload folder has any files
-	payloadPath := filepath.Join(projectDir, "payload")
-	hasPayloadFiles, err := payloadDirectoryHasFiles(payloadPath)
-	if err != nil {
-		return fmt.Errorf("failed to check payload folder: %w", err)
-	}
-
 	installLocation := normalizeInstallLocation(buildInfo.InstallLocation)
 
 	var scriptBuilder strings.Builder
 	scriptBuilder.WriteString("$ErrorActionPreference = 'Stop'\n\n")
-	scriptBuilder.WriteString(fmt.Sprintf("$installLocation = '%s'\n\n", installLocation))
+	scriptBuilder.WriteString(fmt.Sprintf("$installLocation = '%s'\n", installLocation))
+	scriptBuilder.WriteString(fmt.Sprintf("$packageId = '%s'\n", buildInfo.Product.Identifier))
+	scriptBuilder.WriteString(fmt.Sprintf("$packageVersion = '%s'\n\n", buildInfo.Product.Version))
 
-	// If the payload folder actually has files, do the normal create/copy
-	if hasPayloadFiles {
+	// If the manifest has entries, have the install script deploy
+	// content-addressed, skipping files whose destination hash already matches.
+	if len(manifest) > 0 {
 		scriptBuilder.WriteString(`if ($installLocation -and $installLocation -ne '') {
     try {
         New-Item -ItemType Directory -Force -Path $installLocation | Out-Null
@@ -259,30 +297,50 @@ func createChocolateyInstallScript(buildInfo *BuildInfo, projectDir string) erro
     Write-Host "No install location specified, skipping creation of directories."
 }
 
+$manifestPath = Join-Path $PSScriptRoot 'manifest.json'
 $payloadPath = "$PSScriptRoot\..\payload"
 $payloadPath = [System.IO.Path]::GetFullPath($payloadPath)
-$payloadPath = $payloadPath.TrimEnd('\', '/')
-
-Write-Host "Payload path: $payloadPath"
-Get-ChildItem -Path $payloadPath -Recurse | ForEach-Object {
-    $fullName = $_.FullName
-    $relativePath = $fullName.Substring($payloadPath.Length)
-    $relativePath = $relativePath.TrimStart('\', '/')
-    $destinationPath = Join-Path $installLocation $relativePath
-
-    if ($_.PSIsContainer) {
-        New-Item -ItemType Directory -Force -Path $destinationPath | Out-Null
-        Write-Host "Created directory: $destinationPath"
-    } else {
-        Copy-Item -Path $fullName -Destination $destinationPath -Force
-        Write-Host "Copied: $($fullName) -> $destinationPath"
+$receiptDir = Join-Path $env:ProgramData "gorilla\receipts\$packageId"
+$receiptPath = Join-Path $receiptDir "$packageVersion.json"
+
+$manifest = Get-Content -Path $manifestPath -Raw | ConvertFrom-Json
+$installed = @()
+
+foreach ($entry in $manifest) {
+    $destinationPath = Join-Path $installLocation $entry.path
+    $destinationDir = Split-Path -Path $destinationPath -Parent
+    New-Item -ItemType Directory -Force -Path $destinationDir | Out-Null
+
+    $needsCopy = $true
+    if (Test-Path -Path $destinationPath) {
+        $existingHash = (Get-FileHash -Path $destinationPath -Algorithm SHA256).Hash
+        if ($existingHash -eq $entry.sha256) {
+            $needsCopy = $false
+            Write-Host "Unchanged, skipping: $destinationPath"
+        }
+    }
+
+    if ($needsCopy) {
+        $sourcePath = Join-Path $payloadPath $entry.path
+        Copy-Item -Path $sourcePath -Destination $destinationPath -Force
+        Write-Host "Copied: $sourcePath -> $destinationPath"
 
         if (-not (Test-Path -Path $destinationPath)) {
-            Write-Error "Failed to copy: $($fullName)"
+            Write-Error "Failed to copy: $sourcePath"
             exit 1
         }
     }
+
+    $installed += [PSCustomObject]@{
+        path   = $entry.path
+        target = $destinationPath
+        sha256 = $entry.sha256
+    }
 }
+
+New-Item -ItemType Directory -Force -Path $receiptDir | Out-Null
+$installed | ConvertTo-Json -Depth 4 | Set-Content -Path $receiptPath
+Write-Host "Wrote install receipt: $receiptPath"
 `)
 	} else {
 		// Script-only scenario
@@ -345,8 +403,59 @@ Get-ChildItem -Path $payloadPath -Recurse | ForEach-Object {
 	return nil
 }
 
-// generateNuspec builds the .nuspec file
-func generateNuspec(buildInfo *BuildInfo, projectDir string) (string, error) {
+// createChocolateyUninstallScript generates chocolateyUninstall.ps1, which
+// removes only the files recorded in the install receipt whose on-disk hash
+// still matches what was deployed, leaving user-modified files in place.
+func createChocolateyUninstallScript(buildInfo *BuildInfo, projectDir string, manifest []ManifestEntry) error {
+	scriptPath := filepath.Join(projectDir, "tools", "chocolateyUninstall.ps1")
+
+	if len(manifest) == 0 {
+		return nil
+	}
+
+	var scriptBuilder strings.Builder
+	scriptBuilder.WriteString("$ErrorActionPreference = 'Stop'\n\n")
+	scriptBuilder.WriteString(fmt.Sprintf("$packageId = '%s'\n", buildInfo.Product.Identifier))
+	scriptBuilder.WriteString(fmt.Sprintf("$packageVersion = '%s'\n\n", buildInfo.Product.Version))
+	scriptBuilder.WriteString(`$receiptPath = Join-Path $env:ProgramData "gorilla\receipts\$packageId\$packageVersion.json"
+
+if (-not (Test-Path -Path $receiptPath)) {
+    Write-Host "No receipt found for $packageId $packageVersion; nothing to roll back."
+    exit 0
+}
+
+$receipt = Get-Content -Path $receiptPath -Raw | ConvertFrom-Json
+
+foreach ($entry in $receipt) {
+    if (-not (Test-Path -Path $entry.target)) {
+        continue
+    }
+
+    $currentHash = (Get-FileHash -Path $entry.target -Algorithm SHA256).Hash
+    if ($currentHash -eq $entry.sha256) {
+        Remove-Item -Path $entry.target -Force
+        Write-Host "Removed: $($entry.target)"
+    } else {
+        Write-Host "Skipping modified file: $($entry.target)"
+    }
+}
+
+Remove-Item -Path $receiptPath -Force
+`)
+
+	if err := os.MkdirAll(filepath.Dir(scriptPath), os.ModePerm); err != nil {
+		return fmt.Errorf("failed to create tools directory: %w", err)
+	}
+	if err := os.WriteFile(scriptPath, []byte(scriptBuilder.String()), 0644); err != nil {
+		return fmt.Errorf("failed to write chocolateyUninstall.ps1: %w", err)
+	}
+
+	return nil
+}
+
+// generateNuspec builds the .nuspec file. Its payload entries come from
+// manifest (see buildPayloadManifest) rather than a second walk of payload/.
+func generateNuspec(buildInfo *BuildInfo, projectDir string, manifest []ManifestEntry) (string, error) {
 	nuspecPath := filepath.Join(projectDir, buildInfo.Product.Name+".nuspec")
 
 	description := buildInfo.Product.Description
@@ -368,25 +477,12 @@ func generateNuspec(buildInfo *BuildInfo, projectDir string) (string, error) {
 		},
 	}
 
-	payloadPath := filepath.Join(projectDir, "payload")
-	if _, err := os.Stat(payloadPath); !os.IsNotExist(err) {
-		err := filepath.Walk(payloadPath, func(path string, info fs.FileInfo, err error) error {
-			if err != nil {
-				return err
-			}
-			if !info.IsDir() {
-				relPath, _ := filepath.Rel(projectDir, path)
-				relPath = filepath.ToSlash(relPath)
-				nuspec.Files = append(nuspec.Files, FileRef{
-					Src:    relPath,
-					Target: relPath,
-				})
-			}
-			return nil
+	for _, entry := range manifest {
+		relPath := filepath.Join("payload", filepath.FromSlash(entry.Path))
+		nuspec.Files = append(nuspec.Files, FileRef{
+			Src:    relPath,
+			Target: relPath,
 		})
-		if err != nil {
-			return "", fmt.Errorf("error walking payload directory: %w", err)
-		}
 	}
 
 	// Always include chocolateyInstall.ps1
@@ -395,6 +491,19 @@ func generateNuspec(buildInfo *BuildInfo, projectDir string) (string, error) {
 		Target: filepath.Join("tools", "chocolateyInstall.ps1"),
 	})
 
+	// If the payload has files, chocolateyInstall.ps1 deploys from a content-addressed
+	// manifest and chocolateyUninstall.ps1 rolls back using the resulting receipt.
+	if len(manifest) > 0 {
+		nuspec.Files = append(nuspec.Files, FileRef{
+			Src:    filepath.Join("tools", "manifest.json"),
+			Target: filepath.Join("tools", "manifest.json"),
+		})
+		nuspec.Files = append(nuspec.Files, FileRef{
+			Src:    filepath.Join("tools", "chocolateyUninstall.ps1"),
+			Target: filepath.Join("tools", "chocolateyUninstall.ps1"),
+		})
+	}
+
 	// If we have preinstall scripts, they are combined into chocolateyBeforeModify.ps1
 	preScripts, err := getPreinstallScripts(projectDir)
 	if err != nil {
@@ -435,13 +544,34 @@ func runCommand(command string, args ...string) error {
 	return cmd.Run()
 }
 
-func signPackage(nupkgFile, certificate string) error {
-	log.Printf("Signing package: %s with certificate: %s", nupkgFile, certificate)
-	return runCommand(
-		"signtool", "sign", "/n", certificate,
-		"/fd", "SHA256", "/tr", "http://timestamp.digicert.com",
-		"/td", "SHA256", nupkgFile,
-	)
+// signingConfig resolves build-info.yaml's signing configuration, falling
+// back to the legacy signing_certificate field as a local-provider key_id.
+func signingConfig(buildInfo *BuildInfo) signing.Config {
+	cfg := buildInfo.Signing
+	if cfg.Provider == "" && cfg.KeyID == "" && buildInfo.SigningCertificate != "" {
+		cfg.Provider = "local"
+		cfg.KeyID = buildInfo.SigningCertificate
+	}
+	return cfg
+}
+
+// signPackage signs path using the configured signing backend. For .nupkg
+// files, nuget_sign: true selects NuGet's native package signature instead
+// of an authenticode signature over the archive.
+func signPackage(buildInfo *BuildInfo, path string) error {
+	cfg := signingConfig(buildInfo)
+
+	if buildInfo.NuGetSign && strings.HasSuffix(strings.ToLower(path), ".nupkg") {
+		log.Printf("Signing package with NuGet package signature: %s", path)
+		return signing.NewNuGetSigner(cfg).Sign(path)
+	}
+
+	signer, err := signing.New(cfg)
+	if err != nil {
+		return err
+	}
+	log.Printf("Signing package: %s with provider: %s", path, cfg.Provider)
+	return signer.Sign(path)
 }
 
 func checkNuGet() {
@@ -452,12 +582,6 @@ You can install it via Chocolatey:
 	}
 }
 
-func checkSignTool() {
-	if err := runCommand("signtool", "-?"); err != nil {
-		log.Fatalf("SignTool is not installed or not available: %v", err)
-	}
-}
-
 func payloadDirectoryHasFiles(payloadDir string) (bool, error) {
 	if _, err := os.Stat(payloadDir); os.IsNotExist(err) {
 		// Payload folder doesn't exist at all
@@ -479,9 +603,123 @@ func payloadDirectoryHasFiles(payloadDir string) (bool, error) {
 	return hasFiles, err
 }
 
+// sha256File returns the lowercase hex SHA-256 digest of the file at path.
+func sha256File(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// buildPayloadManifest walks the payload directory and records, for each
+// file, its path relative to payload/, its destination under
+// installLocation, its SHA-256, size and mode. Stat and hashing run on a
+// worker pool bounded by jobs (typically runtime.NumCPU()), since hashing is
+// the dominant cost for payloads bundling multi-GB vendor installers; the
+// result is sorted by path so the manifest is byte-identical across repeated
+// builds regardless of how work was scheduled.
+func buildPayloadManifest(projectDir, installLocation string, jobs int) ([]ManifestEntry, error) {
+	payloadPath := filepath.Join(projectDir, "payload")
+	if _, err := os.Stat(payloadPath); os.IsNotExist(err) {
+		return nil, nil
+	}
+
+	var files []string
+	err := filepath.WalkDir(payloadPath, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() {
+			files = append(files, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error walking payload directory: %w", err)
+	}
+
+	if jobs < 1 {
+		jobs = 1
+	}
+
+	entries := make([]ManifestEntry, len(files))
+	sem := make(chan struct{}, jobs)
+	g, _ := errgroup.WithContext(context.Background())
+
+	for i, path := range files {
+		i, path := i, path
+		g.Go(func() error {
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			info, err := os.Lstat(path)
+			if err != nil {
+				return fmt.Errorf("failed to stat %s: %w", path, err)
+			}
+
+			relPath, err := filepath.Rel(payloadPath, path)
+			if err != nil {
+				return err
+			}
+			relPath = filepath.ToSlash(relPath)
+
+			sum, err := sha256File(path)
+			if err != nil {
+				return fmt.Errorf("failed to hash %s: %w", path, err)
+			}
+
+			entries[i] = ManifestEntry{
+				Path:   relPath,
+				Target: filepath.ToSlash(filepath.Join(installLocation, relPath)),
+				SHA256: sum,
+				Size:   info.Size(),
+				Mode:   uint32(info.Mode().Perm()),
+			}
+			return nil
+		})
+	}
+
+	if err := g.Wait(); err != nil {
+		return nil, err
+	}
+
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Path < entries[j].Path })
+	return entries, nil
+}
+
+// writeManifest serializes entries to tools/manifest.json and returns its path.
+func writeManifest(projectDir string, entries []ManifestEntry) (string, error) {
+	manifestPath := filepath.Join(projectDir, "tools", "manifest.json")
+	if err := os.MkdirAll(filepath.Dir(manifestPath), os.ModePerm); err != nil {
+		return "", fmt.Errorf("failed to create tools directory: %w", err)
+	}
+
+	data, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("failed to encode manifest.json: %w", err)
+	}
+
+	if err := os.WriteFile(manifestPath, data, 0644); err != nil {
+		return "", fmt.Errorf("failed to write manifest.json: %w", err)
+	}
+
+	return manifestPath, nil
+}
+
 func main() {
 	var verbose bool
+	var offline bool
+	var jobs int
 	flag.BoolVar(&verbose, "verbose", false, "Enable verbose logging")
+	flag.BoolVar(&offline, "offline", false, "Only use cached sources; fail instead of fetching")
+	flag.IntVar(&jobs, "jobs", runtime.NumCPU(), "Number of concurrent workers for hashing the payload")
 	flag.Parse()
 
 	if flag.NArg() < 1 {
@@ -492,16 +730,26 @@ func main() {
 	setupLogging(verbose)
 	log.Printf("Using project directory: %s", projectDir)
 
-	if err := verifyProjectStructure(projectDir); err != nil {
-		log.Fatalf("Error verifying project structure: %v", err)
-	}
-	log.Println("Project structure verified. Proceeding with package creation...")
-
 	buildInfo, err := readBuildInfo(projectDir)
 	if err != nil {
 		log.Fatalf("Error reading build-info.yaml: %v", err)
 	}
 
+	// Resolve declarative sources into payload/ before verifying project
+	// structure, since sources may be what populates payload/ in the first place.
+	if len(buildInfo.Sources) > 0 {
+		cacheDir := filepath.Join(os.TempDir(), "gorilla-pkg", "sources")
+		if err := fetchsrc.FetchAll(buildInfo.Sources, projectDir, cacheDir, offline); err != nil {
+			log.Fatalf("Error fetching sources: %v", err)
+		}
+		log.Println("Sources fetched successfully.")
+	}
+
+	if err := verifyProjectStructure(projectDir); err != nil {
+		log.Fatalf("Error verifying project structure: %v", err)
+	}
+	log.Println("Project structure verified. Proceeding with package creation...")
+
 	// Check if the payload folder exists and has files
 	payloadPath := filepath.Join(projectDir, "payload")
 	hasPayloadFiles, err := payloadDirectoryHasFiles(payloadPath)
@@ -524,31 +772,91 @@ func main() {
 	}
 	log.Println("Directories created successfully.")
 
+	formats := buildInfo.Formats
+	if len(formats) == 0 {
+		formats = []string{"nupkg"}
+	}
+
+	buildDir := filepath.Join(projectDir, "build")
+
+	var nfpmFormats []string
+	for _, format := range formats {
+		switch format {
+		case "nupkg":
+			finalPkgPath, err := buildNupkgPackage(buildInfo, projectDir, buildDir, jobs)
+			if err != nil {
+				log.Fatalf("Error building .nupkg: %v", err)
+			}
+			log.Printf("Package created successfully: %s", finalPkgPath)
+		case "msi":
+			msiPath, err := buildMsiPackage(buildInfo, projectDir, buildDir)
+			if err != nil {
+				log.Fatalf("Error building .msi: %v", err)
+			}
+			log.Printf("Package created successfully: %s", msiPath)
+		default:
+			nfpmFormats = append(nfpmFormats, format)
+		}
+	}
+
+	if len(nfpmFormats) > 0 {
+		artifacts, err := buildNfpmPackages(buildInfo, projectDir, buildDir, nfpmFormats)
+		if err != nil {
+			log.Fatalf("Error building nfpm packages: %v", err)
+		}
+		for _, artifact := range artifacts {
+			log.Printf("Package created successfully: %s", artifact)
+		}
+	}
+}
+
+// buildNupkgPackage generates the Chocolatey install script and .nuspec,
+// packs them into a .nupkg with nuget.exe, signs the result if requested,
+// and returns the path to the final package.
+func buildNupkgPackage(buildInfo *BuildInfo, projectDir, buildDir string, jobs int) (string, error) {
 	// Include all preinstall scripts
 	if err := includePreinstallScripts(projectDir); err != nil {
-		log.Fatalf("Error including preinstall scripts: %v", err)
+		return "", fmt.Errorf("error including preinstall scripts: %w", err)
 	}
 
-	// Create chocolateyInstall.ps1 (and optionally copy payload / append postinstall scripts)
-	if err := createChocolateyInstallScript(buildInfo, projectDir); err != nil {
-		log.Fatalf("Error generating chocolateyInstall.ps1: %v", err)
+	// Compute the payload manifest once and share it across the install
+	// script, uninstall script and .nuspec, instead of walking payload/ three times.
+	// Target is derived from the same normalized install location the
+	// generated scripts use, so manifest.json doesn't disagree with them.
+	manifest, err := buildPayloadManifest(projectDir, normalizeInstallLocation(buildInfo.InstallLocation), jobs)
+	if err != nil {
+		return "", fmt.Errorf("failed to build manifest: %w", err)
+	}
+	if len(manifest) > 0 {
+		if _, err := writeManifest(projectDir, manifest); err != nil {
+			return "", fmt.Errorf("failed to write manifest: %w", err)
+		}
 	}
 
-	nuspecPath, err := generateNuspec(buildInfo, projectDir)
+	// Create chocolateyInstall.ps1 (manifest-driven payload deploy, plus postinstall scripts)
+	if err := createChocolateyInstallScript(buildInfo, projectDir, manifest); err != nil {
+		return "", fmt.Errorf("error generating chocolateyInstall.ps1: %w", err)
+	}
+
+	// Create chocolateyUninstall.ps1, which rolls back using the install receipt
+	if err := createChocolateyUninstallScript(buildInfo, projectDir, manifest); err != nil {
+		return "", fmt.Errorf("error generating chocolateyUninstall.ps1: %w", err)
+	}
+
+	nuspecPath, err := generateNuspec(buildInfo, projectDir, manifest)
 	if err != nil {
-		log.Fatalf("Error generating .nuspec: %v", err)
+		return "", fmt.Errorf("error generating .nuspec: %w", err)
 	}
 	defer os.Remove(nuspecPath)
 	log.Printf(".nuspec generated at: %s", nuspecPath)
 
 	checkNuGet()
 
-	buildDir := filepath.Join(projectDir, "build")
 	builtPkgName := buildInfo.Product.Name + "-" + buildInfo.Product.Version + ".nupkg"
 	builtPkgPath := filepath.Join(buildDir, builtPkgName)
 
 	if err := runCommand("nuget", "pack", nuspecPath, "-OutputDirectory", buildDir, "-NoPackageAnalysis"); err != nil {
-		log.Fatalf("Error creating package: %v", err)
+		return "", fmt.Errorf("error creating package: %w", err)
 	}
 
 	searchPattern := filepath.Join(buildDir, buildInfo.Product.Identifier+"*.nupkg")
@@ -558,7 +866,7 @@ func main() {
 	if len(matches) > 0 {
 		log.Printf("Renaming package: %s to %s", matches[0], builtPkgPath)
 		if err := os.Rename(matches[0], builtPkgPath); err != nil {
-			log.Fatalf("Failed to rename package: %v", err)
+			return "", fmt.Errorf("failed to rename package: %w", err)
 		}
 		finalPkgPath = builtPkgPath
 	} else {
@@ -566,11 +874,10 @@ func main() {
 		finalPkgPath = builtPkgPath
 	}
 
-	// Sign if specified
-	if buildInfo.SigningCertificate != "" {
-		checkSignTool()
-		if err := signPackage(finalPkgPath, buildInfo.SigningCertificate); err != nil {
-			log.Fatalf("Failed to sign package %s: %v", finalPkgPath, err)
+	// Sign if a signing backend is configured
+	if signingConfig(buildInfo).KeyID != "" {
+		if err := signPackage(buildInfo, finalPkgPath); err != nil {
+			return "", fmt.Errorf("failed to sign package %s: %w", finalPkgPath, err)
 		}
 	} else {
 		log.Println("No signing certificate provided. Skipping signing.")
@@ -584,5 +891,65 @@ func main() {
 		log.Println("Tools directory removed successfully.")
 	}
 
-	log.Printf("Package created successfully: %s", finalPkgPath)
+	return finalPkgPath, nil
+}
+
+// buildNfpmPackages translates build-info.yaml and the payload into nfpm
+// packages for every requested Linux format (deb, rpm, apk, archlinux).
+func buildNfpmPackages(buildInfo *BuildInfo, projectDir, buildDir string, formats []string) ([]string, error) {
+	in := nfpmbuild.Input{
+		Identifier:        buildInfo.Product.Identifier,
+		Name:              buildInfo.Product.Name,
+		Version:           buildInfo.Product.Version,
+		Developer:         buildInfo.Product.Developer,
+		Description:       buildInfo.Product.Description,
+		InstallLocation:   linuxInstallLocation(buildInfo),
+		PayloadDir:        filepath.Join(projectDir, "payload"),
+		PreinstallScript:  scriptPath(projectDir, "preinstall.sh"),
+		PostinstallScript: scriptPath(projectDir, "postinstall.sh"),
+	}
+
+	return nfpmbuild.Build(in, formats, buildDir)
+}
+
+// buildMsiPackage translates build-info.yaml and the payload into a WiX
+// source, compiles it into a .msi, signs it through the configured signing
+// backend, and returns its path.
+func buildMsiPackage(buildInfo *BuildInfo, projectDir, buildDir string) (string, error) {
+	in := wixbuild.Input{
+		Identifier:        buildInfo.Product.Identifier,
+		Name:              buildInfo.Product.Name,
+		Version:           buildInfo.Product.Version,
+		Developer:         buildInfo.Product.Developer,
+		Description:       buildInfo.Product.Description,
+		InstallLocation:   buildInfo.InstallLocation,
+		PayloadDir:        filepath.Join(projectDir, "payload"),
+		PreinstallScript:  scriptPath(projectDir, "preinstall.ps1"),
+		PostinstallScript: scriptPath(projectDir, "postinstall.ps1"),
+	}
+
+	msiPath, err := wixbuild.Build(in, buildDir)
+	if err != nil {
+		return "", err
+	}
+
+	if signingConfig(buildInfo).KeyID != "" {
+		if err := signPackage(buildInfo, msiPath); err != nil {
+			return "", fmt.Errorf("failed to sign package %s: %w", msiPath, err)
+		}
+	} else {
+		log.Println("No signing certificate provided. Skipping signing.")
+	}
+
+	return msiPath, nil
+}
+
+// scriptPath returns the path to name under the project's scripts directory
+// if it exists, or the empty string otherwise.
+func scriptPath(projectDir, name string) string {
+	path := filepath.Join(projectDir, "scripts", name)
+	if _, err := os.Stat(path); err != nil {
+		return ""
+	}
+	return path
 }
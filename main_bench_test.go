@@ -0,0 +1,41 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+// BenchmarkBuildPayloadManifest measures the parallel hashing walk against a
+// payload of many mid-sized files, the shape that motivated bounding the
+// worker pool instead of hashing serially.
+func BenchmarkBuildPayloadManifest(b *testing.B) {
+	projectDir, err := os.MkdirTemp("", "gorilla-pkg-bench")
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer os.RemoveAll(projectDir)
+
+	payloadDir := filepath.Join(projectDir, "payload")
+	if err := os.MkdirAll(payloadDir, 0755); err != nil {
+		b.Fatal(err)
+	}
+
+	const fileCount = 200
+	data := make([]byte, 64*1024)
+	for i := 0; i < fileCount; i++ {
+		path := filepath.Join(payloadDir, fmt.Sprintf("file-%03d.bin", i))
+		if err := os.WriteFile(path, data, 0644); err != nil {
+			b.Fatal(err)
+		}
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := buildPayloadManifest(projectDir, `C:\Program Files\Example`, runtime.NumCPU()); err != nil {
+			b.Fatal(err)
+		}
+	}
+}